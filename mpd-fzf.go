@@ -3,17 +3,24 @@ package main
 import (
 	"bufio"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/aver-d/mpd-fzf/mpd"
+	"github.com/aver-d/mpd-fzf/tags"
 	runewidth "github.com/mattn/go-runewidth"
 )
 
@@ -124,22 +131,26 @@ func termWidth() int {
 	return width
 }
 
+func trackInfo(t *Track) string {
+	info := t.Title
+	if info == "" {
+		info = withoutExt(t.Filename)
+	}
+	if t.Artist != "" {
+		info = t.Artist + " - " + info
+	}
+	if t.Album != "" {
+		info += " {" + t.Album + "}"
+	}
+	return info
+}
+
 func trackFormatter() func(*Track) string {
 	// Remove 5 from screen width for correct fzf display at right edge.
 	// Then a further one for the delimiter between info and duration.
 	width := termWidth() - 5 - 1
 	return func(t *Track) string {
-		info := t.Title
-		if info == "" {
-			info = withoutExt(t.Filename)
-		}
-		if t.Artist != "" {
-			info = t.Artist + " - " + info
-		}
-		if t.Album != "" {
-			info += " {" + t.Album + "}"
-		}
-		info = strings.Replace(info, delimiter, " ", -1)
+		info := strings.Replace(trackInfo(t), delimiter, " ", -1)
 		duration := formatDurationString(t.Time)
 		// Right align duration
 		info = alignLeftRight(width-len(duration), info, delimiter+duration)
@@ -147,6 +158,16 @@ func trackFormatter() func(*Track) string {
 	}
 }
 
+func sumDurations(times []string) string {
+	var total float64
+	for _, s := range times {
+		if d, err := strconv.ParseFloat(s, 64); err == nil {
+			total += d
+		}
+	}
+	return strconv.FormatFloat(total, 'f', 0, 64)
+}
+
 func groupByArtist(tracks []*Track) []*Track {
 	// group by artist, then shuffle to stop same order, but keep artist together
 	artists := map[string][]*Track{}
@@ -164,6 +185,54 @@ func groupByArtist(tracks []*Track) []*Track {
 	return shuffled
 }
 
+type Album struct {
+	Artist string
+	Name   string
+	Tracks []*Track
+}
+
+func albumKey(artist, name string) string {
+	return artist + "\x1f" + name
+}
+
+func splitAlbumKey(key string) (artist, name string) {
+	parts := strings.SplitN(key, "\x1f", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func groupByAlbum(tracks []*Track) []*Album {
+	order := []string{}
+	albums := map[string]*Album{}
+	for _, t := range tracks {
+		key := albumKey(t.Artist, t.Album)
+		a, ok := albums[key]
+		if !ok {
+			a = &Album{Artist: t.Artist, Name: t.Album}
+			albums[key] = a
+			order = append(order, key)
+		}
+		a.Tracks = append(a.Tracks, t)
+	}
+	sorted := make([]*Album, len(order))
+	for i, key := range order {
+		sorted[i] = albums[key]
+	}
+	return sorted
+}
+
+func tracksInAlbum(tracks []*Track, artist, name string) []*Track {
+	var out []*Track
+	for _, t := range tracks {
+		if t.Artist == artist && t.Album == name {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
 func parse(scan *bufio.Scanner) []*Track {
 
 	tracks, track := []*Track{}, new(Track)
@@ -197,41 +266,19 @@ func expandUser(path, home string) string {
 	return path
 }
 
-func findDbFile() string {
+func homeDir() string {
 	usr, err := user.Current()
 	fail(err)
-	home := usr.HomeDir
-	paths := []string{
-		filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "/mpd/mpd.conf"),
-		filepath.Join(home, ".config", "/mpd/mpd.conf"),
-		filepath.Join(home, ".mpdconf"),
-		"/etc/mpd.conf",
-	}
-	var file *os.File
-	var confpath string
-	for _, path := range paths {
-		file, err = os.Open(path)
-		if err == nil {
-			confpath = path
-			break
-		}
-	}
-	failOn(file == nil, "No config file found")
+	return usr.HomeDir
+}
 
-	expDb := regexp.MustCompile(`^\s*db_file\s*"([^"]+)"`)
-	scan := bufio.NewScanner(file)
-	var dbFile string
-	for scan.Scan() {
-		m := expDb.FindStringSubmatch(scan.Text())
-		if m != nil {
-			dbFile = expandUser(m[1], home)
-			break
-		}
-	}
-	fail(scan.Err())
-	fail(file.Close())
-	failOn(dbFile == "", fmt.Sprintf("Could not find 'db_file' in configuration file '%s'", confpath))
-	return dbFile
+func findDbFile() string {
+	confpath, err := mpd.FindConfigFile()
+	fail(err)
+	cfg, err := mpd.ParseConfig(confpath)
+	fail(err)
+	failOn(cfg.DbFile == "", fmt.Sprintf("Could not find 'db_file' in configuration file '%s'", confpath))
+	return cfg.DbFile
 }
 
 func failNotify(message string) {
@@ -242,55 +289,271 @@ func failNotify(message string) {
 	fail(errors.New(message))
 }
 
-func mpcRun(args ...string) string {
-	out, err := exec.Command("mpc", args...).CombinedOutput()
+// connectMPD resolves the MPD address and password, preferring (in
+// order) the --host/--port/--password flags, a "password@host" MPD_HOST
+// prefix, MPD_HOST/MPD_PORT, then bind_to_address/port/password from
+// mpd.conf, and finally connects and authenticates if a password was
+// found.
+func connectMPD(flagHost, flagPort, flagPassword string) (*mpd.Client, error) {
+	host, port, password := "localhost", "6600", ""
+
+	if confpath, err := mpd.FindConfigFile(); err == nil {
+		if cfg, err := mpd.ParseConfig(confpath); err == nil {
+			if cfg.BindToAddress != "" {
+				host = cfg.BindToAddress
+			}
+			if cfg.Port != "" {
+				port = cfg.Port
+			}
+			if cfg.Password != "" {
+				password = cfg.Password
+			}
+		}
+	}
+
+	if envHost := os.Getenv("MPD_HOST"); envHost != "" {
+		host = envHost
+	}
+	if envPort := os.Getenv("MPD_PORT"); envPort != "" {
+		port = envPort
+	}
+	if i := strings.Index(host, "@"); i >= 0 {
+		password, host = host[:i], host[i+1:]
+	}
+
+	if flagHost != "" {
+		host = flagHost
+	}
+	if flagPort != "" {
+		port = flagPort
+	}
+	if flagPassword != "" {
+		password = flagPassword
+	}
+
+	network, addr := "tcp", host+":"+port
+	if strings.HasPrefix(host, "/") {
+		network, addr = "unix", host
+	}
+
+	client, err := mpd.Dial(network, addr)
 	if err != nil {
-		failNotify(string(out))
+		return nil, err
+	}
+	if password != "" {
+		if err := client.Password(password); err != nil {
+			client.Close()
+			return nil, err
+		}
 	}
-	return string(out)
+	return client, nil
 }
 
-func mpcSelect(path string, play bool) {
-	pos, found := mpcFindOnPlaylist(path)
+func dialMPD(flagHost, flagPort, flagPassword string) *mpd.Client {
+	client, err := connectMPD(flagHost, flagPort, flagPassword)
+	fail(err)
+	return client
+}
+
+func findOnPlaylist(client *mpd.Client, path string) (int, bool) {
+	songs, err := client.PlaylistInfo()
+	fail(err)
+	for _, s := range songs {
+		if s.Path == path {
+			return s.Pos, true
+		}
+	}
+	return 0, false
+}
+
+func mpdSelect(client *mpd.Client, path string, play bool) {
+	pos, found := findOnPlaylist(client, path)
 	if !found {
-		mpcRun("add", path)
+		fail(client.Add(path))
+		pos, _ = findOnPlaylist(client, path)
 	}
 	if play {
-		mpcRun("play", strconv.Itoa(pos))
+		fail(client.Play(pos))
+	}
+}
+
+// lastField returns the final delimiter-separated field of an fzf line,
+// which by convention across mpd-fzf's 3-field row formats (info|duration|id)
+// is the machine-readable id.
+func lastField(line string) string {
+	fields := strings.SplitN(line, delimiter, 3)
+	if len(fields) != 3 {
+		failNotify("mpd-fzf: split assertion failure")
 	}
+	return fields[2]
+}
+
+func cmdSelect(client *mpd.Client, fzfline string, play bool) {
+	mpdSelect(client, lastField(fzfline), play)
 }
 
-func mpcFindOnPlaylist(path string) (int, bool) {
-	playlist := mpcRun("playlist", "-f", "%file%")
-	lines := strings.Split(playlist, "\n")
-	for i, line := range lines {
-		if line == path {
-			return i + 1, true
+const (
+	trackBindings    = "enter:execute-silent(mpd-fzf _play {}),alt-enter:execute-silent(mpd-fzf _queue {})"
+	playlistBindings = "enter:execute-silent(mpd-fzf _playlist-play {})," +
+		"alt-enter:execute-silent(mpd-fzf _playlist-append {})"
+	unifiedBindings = "enter:execute(mpd-fzf _unified-enter {}),alt-enter:execute-silent(mpd-fzf _unified-queue {})"
+
+	// jukeboxBindings are layered onto every fzf prompt so playback can be
+	// driven without leaving the picker. Keys are chosen to avoid fzf's own
+	// defaults: ctrl-n/ctrl-p are fzf's down/up, and +/- are ordinary query
+	// characters, so next/prev and volume live on alt- letter combinations
+	// instead, the same unambiguous alt-<letter> form fzf uses everywhere
+	// else, rather than risking a punctuation key fzf's --bind parser might
+	// reject (which would break every prompt, not just this one).
+	// fzf has no periodic timer event, so the --header status line is only
+	// ever refreshed on demand, by pressing ctrl-l.
+	jukeboxBindings = "ctrl-o:execute(mpd-fzf _outputs)," +
+		"ctrl-space:execute-silent(mpd-fzf _pause)," +
+		"alt-n:execute-silent(mpd-fzf _next)," +
+		"alt-p:execute-silent(mpd-fzf _prev)," +
+		"ctrl-r:execute-silent(mpd-fzf _random)," +
+		"ctrl-x:execute-silent(mpd-fzf _clear)," +
+		"alt-u:execute-silent(mpd-fzf _volup)," +
+		"alt-d:execute-silent(mpd-fzf _voldown)," +
+		"ctrl-l:transform-header(mpd-fzf _header)"
+)
+
+func fzfcmd(nth, bind string) *exec.Cmd {
+	args := []string{
+		"--no-hscroll",
+		"--nth", nth,
+		"--delimiter", delimiter,
+		"--bind", bind + "," + jukeboxBindings,
+	}
+	if header := headerText(); header != "" {
+		args = append(args, "--header", header)
+	}
+	fzf := exec.Command("fzf", args...)
+	fzf.Stderr = os.Stderr
+	return fzf
+}
+
+// headerText reports the current MPD status for fzf's --header, or ""
+// if MPD isn't reachable.
+func headerText() string {
+	client, err := connectMPD("", "", "")
+	if err != nil {
+		return ""
+	}
+	defer client.Close()
+	text, err := statusHeader(client)
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+func statusHeader(client *mpd.Client) (string, error) {
+	st, err := client.Status()
+	if err != nil {
+		return "", err
+	}
+	song, err := client.CurrentSong()
+	if err != nil {
+		return "", err
+	}
+	info := song.Artist
+	if info != "" && song.Title != "" {
+		info += " - "
+	}
+	info += song.Title
+	if info == "" {
+		info = withoutExt(song.Path)
+	}
+	elapsed := formatDurationString(strconv.FormatFloat(st.Elapsed, 'f', 0, 64))
+	duration := formatDurationString(strconv.FormatFloat(st.Duration, 'f', 0, 64))
+	random := "off"
+	if st.Random {
+		random = "on"
+	}
+	return fmt.Sprintf("%s  %s  %s/%s  vol:%d%%  random:%s", st.State, info, elapsed, duration, st.Volume, random), nil
+}
+
+func cmdPauseToggle(client *mpd.Client) {
+	st, err := client.Status()
+	fail(err)
+	fail(client.Pause(st.State == "play"))
+}
+
+func cmdRandomToggle(client *mpd.Client) {
+	st, err := client.Status()
+	fail(err)
+	fail(client.SetRandom(!st.Random))
+}
+
+func cmdVolAdjust(client *mpd.Client, delta int) {
+	st, err := client.Status()
+	fail(err)
+	fail(client.SetVol(st.Volume + delta))
+}
+
+func cmdHeader(client *mpd.Client) {
+	text, err := statusHeader(client)
+	if err != nil {
+		text = ""
+	}
+	fmt.Println(text)
+}
+
+func outputFormatter() func(mpd.Output) string {
+	return func(o mpd.Output) string {
+		state := "off"
+		if o.Enabled {
+			state = "on"
 		}
+		info := fmt.Sprintf("[%s] %s", state, o.Name)
+		return info + delimiter + delimiter + o.ID
 	}
-	return len(lines), false
 }
 
-func cmdSelect(fzfline string, play bool) {
-	fields := strings.SplitN(fzfline, delimiter, 3)
-	if len(fields) != 3 {
-		failNotify("mpd-fzf: split assertion failure")
+func cmdOutputsList(client *mpd.Client) {
+	outputs, err := client.Outputs()
+	fail(err)
+	format := outputFormatter()
+	for _, o := range outputs {
+		fmt.Println(format(o))
+	}
+}
+
+func cmdToggleOutput(client *mpd.Client, id string) {
+	outputs, err := client.Outputs()
+	fail(err)
+	for _, o := range outputs {
+		if o.ID == id {
+			if o.Enabled {
+				fail(client.DisableOutput(id))
+			} else {
+				fail(client.EnableOutput(id))
+			}
+			return
+		}
 	}
-	path := fields[2]
-	mpcSelect(path, play)
 }
 
-func fzfcmd() *exec.Cmd {
-	bindPlay := "enter:execute-silent(mpd-fzf _play {})"
-	bindQueue := "alt-enter:execute-silent(mpd-fzf _queue {})"
+func cmdOutputs(client *mpd.Client) {
 	fzf := exec.Command("fzf",
 		"--no-hscroll",
 		"--nth", "1",
 		"--delimiter", delimiter,
-		"--bind", bindPlay+","+bindQueue,
+		"--bind", "enter:execute-silent(mpd-fzf _toggle-output {})+reload(mpd-fzf _outputs-list)",
 	)
 	fzf.Stderr = os.Stderr
-	return fzf
+	in, err := fzf.StdinPipe()
+	fail(err)
+	fail(fzf.Start())
+	outputs, err := client.Outputs()
+	fail(err)
+	format := outputFormatter()
+	for _, o := range outputs {
+		fmt.Fprintln(in, format(o))
+	}
+	fail(in.Close())
+	fail(ignoreExitInterrupt(fzf.Wait()))
 }
 
 func ignoreExitInterrupt(err error) error {
@@ -300,46 +563,660 @@ func ignoreExitInterrupt(err error) error {
 	return err
 }
 
-func cmdList() {
-	dbFile := findDbFile()
+func runTracksFzf(tracks []*Track) {
 	format := trackFormatter()
+	fzf := fzfcmd("1", trackBindings)
+	in, err := fzf.StdinPipe()
+	fail(err)
+	fail(fzf.Start())
+	for _, t := range tracks {
+		fmt.Fprintln(in, format(t))
+	}
+	fail(in.Close())
+	fail(ignoreExitInterrupt(fzf.Wait()))
+}
 
+func loadTracks() []*Track {
+	dbFile := findDbFile()
 	file, err := os.Open(dbFile)
 	fail(err)
 	gz, err := gzip.NewReader(file)
 	fail(err)
 
 	scan := bufio.NewScanner(gz)
-	tracks := groupByArtist(parse(scan))
+	tracks := parse(scan)
 
 	fail(gz.Close())
 	fail(file.Close())
+	return tracks
+}
+
+func cmdList() {
+	runTracksFzf(groupByArtist(loadTracks()))
+}
+
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".m4a":  true,
+}
+
+func tagTrackToTrack(t *tags.Track) *Track {
+	return &Track{
+		Artist:   t.Artist,
+		Album:    t.Album,
+		Date:     t.Date,
+		Filename: t.Filename,
+		Genre:    t.Genre,
+		Path:     t.Path,
+		Time:     t.Time,
+		Title:    t.Title,
+	}
+}
+
+// scanDir walks dir concurrently, reading tags from every audio file with
+// a worker pool sized to the number of CPUs, and returns the tracks found.
+func scanDir(dir string) []*Track {
+	paths := make(chan string)
+	found := make(chan *Track)
+
+	go func() {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			if audioExtensions[strings.ToLower(filepath.Ext(path))] {
+				paths <- path
+			}
+			return nil
+		})
+		close(paths)
+	}()
+
+	var wg sync.WaitGroup
+	reader := tags.NewReader()
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				t, err := reader.Read(path)
+				if err == nil {
+					found <- tagTrackToTrack(t)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	tracks := []*Track{}
+	for t := range found {
+		tracks = append(tracks, t)
+	}
+	return tracks
+}
+
+func cmdScan(dir string) {
+	runTracksFzf(groupByArtist(scanDir(dir)))
+}
+
+func songsToTracks(songs []mpd.Song) []*Track {
+	tracks := make([]*Track, len(songs))
+	for i, s := range songs {
+		tracks[i] = &Track{
+			Artist:   s.Artist,
+			Album:    s.Album,
+			Title:    s.Title,
+			Time:     s.Time,
+			Path:     s.Path,
+			Filename: filepath.Base(s.Path),
+		}
+	}
+	return tracks
+}
+
+func songTimes(songs []mpd.Song) []string {
+	times := make([]string, len(songs))
+	for i, s := range songs {
+		times[i] = s.Time
+	}
+	return times
+}
+
+func trackTimes(tracks []*Track) []string {
+	times := make([]string, len(tracks))
+	for i, t := range tracks {
+		times[i] = t.Time
+	}
+	return times
+}
+
+func playlistFormatter() func(mpd.Playlist, []mpd.Song) string {
+	width := termWidth() - 5 - 1
+	return func(p mpd.Playlist, songs []mpd.Song) string {
+		info := fmt.Sprintf("%s (%d tracks)", p.Name, len(songs))
+		info = strings.Replace(info, delimiter, " ", -1)
+		duration := formatDurationString(sumDurations(songTimes(songs)))
+		info = alignLeftRight(width-len(duration), info, delimiter+duration)
+		return info + delimiter + p.Name
+	}
+}
 
-	fzf := fzfcmd()
+func cmdPlaylists(client *mpd.Client) {
+	playlists, err := client.ListPlaylists()
+	fail(err)
+	format := playlistFormatter()
+
+	fzf := fzfcmd("1", playlistBindings)
 	in, err := fzf.StdinPipe()
 	fail(err)
 	fail(fzf.Start())
+	for _, p := range playlists {
+		songs, err := client.ListPlaylistInfo(p.Name)
+		fail(err)
+		fmt.Fprintln(in, format(p, songs))
+	}
+	fail(in.Close())
+	fail(ignoreExitInterrupt(fzf.Wait()))
+}
+
+func cmdPlaylistTracks(client *mpd.Client, name string) {
+	songs, err := client.ListPlaylistInfo(name)
+	fail(err)
+	runTracksFzf(songsToTracks(songs))
+}
+
+// cmdPlaylistPlay replaces the current queue with a stored playlist's
+// tracks and starts playback, mirroring cmdPlaylistFileLoad's replace mode.
+func cmdPlaylistPlay(client *mpd.Client, name string) {
+	songs, err := client.ListPlaylistInfo(name)
+	fail(err)
+	fail(client.Clear())
+	for _, s := range songs {
+		fail(client.Add(s.Path))
+	}
+	if len(songs) > 0 {
+		fail(client.Play(0))
+	}
+}
+
+// cmdPlaylistAppend adds a stored playlist's tracks to the current queue
+// without disturbing playback, mirroring the playlist branch of
+// cmdUnifiedQueue.
+func cmdPlaylistAppend(client *mpd.Client, name string) {
+	songs, err := client.ListPlaylistInfo(name)
+	fail(err)
+	for _, s := range songs {
+		mpdSelect(client, s.Path, false)
+	}
+}
+
+var playlistFileExtensions = map[string]bool{
+	".m3u":  true,
+	".m3u8": true,
+	".pls":  true,
+	".nsp":  true,
+}
+
+// findPlaylistFiles walks every directory in dirs looking for .m3u,
+// .m3u8, .pls and .nsp files.
+func findPlaylistFiles(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			if playlistFileExtensions[strings.ToLower(filepath.Ext(path))] {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// playlistFileDirs combines any user-supplied directories with MPD's
+// playlist_directory from mpd.conf, if one can be found.
+func playlistFileDirs(extra []string) []string {
+	dirs := append([]string{}, extra...)
+	if confpath, err := mpd.FindConfigFile(); err == nil {
+		if cfg, err := mpd.ParseConfig(confpath); err == nil && cfg.PlaylistDirectory != "" {
+			dirs = append(dirs, cfg.PlaylistDirectory)
+		}
+	}
+	return dirs
+}
+
+// resolvePlaylistEntry resolves a playlist entry's path relative to the
+// playlist file's own directory, honoring ~ expansion and absolute paths.
+func resolvePlaylistEntry(entry, dir string) string {
+	entry = expandUser(entry, homeDir())
+	if filepath.IsAbs(entry) {
+		return entry
+	}
+	return filepath.Join(dir, entry)
+}
+
+// parseExtinf parses a "#EXTINF:<sec>,<artist> - <title>" line.
+func parseExtinf(line string) *Track {
+	rest := strings.TrimPrefix(line, "#EXTINF:")
+	i := strings.Index(rest, ",")
+	if i < 0 {
+		return &Track{}
+	}
+	t := &Track{Time: strings.TrimSpace(rest[:i])}
+	info := rest[i+1:]
+	if parts := strings.SplitN(info, " - ", 2); len(parts) == 2 {
+		t.Artist, t.Title = parts[0], parts[1]
+	} else {
+		t.Title = info
+	}
+	return t
+}
+
+func parseM3U(path string) []*Track {
+	file, err := os.Open(path)
+	fail(err)
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	tracks := []*Track{}
+	var pending *Track
+	scan := bufio.NewScanner(file)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending = parseExtinf(line)
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			t := pending
+			if t == nil {
+				t = &Track{}
+			}
+			pending = nil
+			t.Path = resolvePlaylistEntry(line, dir)
+			t.Filename = filepath.Base(t.Path)
+			tracks = append(tracks, t)
+		}
+	}
+	fail(scan.Err())
+	return tracks
+}
+
+var plsEntryPattern = regexp.MustCompile(`^(File|Title|Length)(\d+)=(.*)$`)
+
+func parsePLS(path string) []*Track {
+	file, err := os.Open(path)
+	fail(err)
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	entries := map[int]*Track{}
+	scan := bufio.NewScanner(file)
+	for scan.Scan() {
+		m := plsEntryPattern.FindStringSubmatch(strings.TrimSpace(scan.Text()))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		t, ok := entries[n]
+		if !ok {
+			t = &Track{}
+			entries[n] = t
+		}
+		switch m[1] {
+		case "File":
+			t.Path = resolvePlaylistEntry(m[3], dir)
+			t.Filename = filepath.Base(t.Path)
+		case "Title":
+			t.Title = m[3]
+		case "Length":
+			t.Time = m[3]
+		}
+	}
+	fail(scan.Err())
+
+	nums := make([]int, 0, len(entries))
+	for n := range entries {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	tracks := make([]*Track, len(nums))
+	for i, n := range nums {
+		tracks[i] = entries[n]
+	}
+	return tracks
+}
+
+// nspCriteria is the schema of mpd-fzf's simple JSON smart-playlist
+// format: a track matches when it satisfies every field that's set.
+type nspCriteria struct {
+	Artist  string `json:"artist,omitempty"`
+	Album   string `json:"album,omitempty"`
+	Genre   string `json:"genre,omitempty"`
+	YearMin int    `json:"year_min,omitempty"`
+	YearMax int    `json:"year_max,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+	Sort    string `json:"sort,omitempty"` // artist, album, title or date
+}
+
+func sortTracksBy(tracks []*Track, by string) {
+	switch by {
+	case "artist":
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].Artist < tracks[j].Artist })
+	case "album":
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].Album < tracks[j].Album })
+	case "title":
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].Title < tracks[j].Title })
+	case "date":
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].Date < tracks[j].Date })
+	}
+}
+
+func parseNSP(path string, allTracks []*Track) []*Track {
+	data, err := os.ReadFile(path)
+	fail(err)
+	var crit nspCriteria
+	fail(json.Unmarshal(data, &crit))
+
+	tracks := []*Track{}
+	for _, t := range allTracks {
+		if crit.Artist != "" && t.Artist != crit.Artist {
+			continue
+		}
+		if crit.Album != "" && t.Album != crit.Album {
+			continue
+		}
+		if crit.Genre != "" && t.Genre != crit.Genre {
+			continue
+		}
+		if year, err := strconv.Atoi(t.Date); err == nil {
+			if crit.YearMin > 0 && year < crit.YearMin {
+				continue
+			}
+			if crit.YearMax > 0 && year > crit.YearMax {
+				continue
+			}
+		} else if crit.YearMin > 0 || crit.YearMax > 0 {
+			continue
+		}
+		tracks = append(tracks, t)
+	}
+	sortTracksBy(tracks, crit.Sort)
+	if crit.Limit > 0 && len(tracks) > crit.Limit {
+		tracks = tracks[:crit.Limit]
+	}
+	return tracks
+}
+
+func loadPlaylistFile(path string, allTracks []*Track) []*Track {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		return parseM3U(path)
+	case ".pls":
+		return parsePLS(path)
+	case ".nsp":
+		return parseNSP(path, allTracks)
+	}
+	return nil
+}
+
+func playlistFileFormatter() func(path string, tracks []*Track) string {
+	width := termWidth() - 5 - 1
+	return func(path string, tracks []*Track) string {
+		info := fmt.Sprintf("%s (%d tracks)", withoutExt(path), len(tracks))
+		info = strings.Replace(info, delimiter, " ", -1)
+		duration := formatDurationString(sumDurations(trackTimes(tracks)))
+		info = alignLeftRight(width-len(duration), info, delimiter+duration)
+		return info + delimiter + path
+	}
+}
+
+const playlistFileBindings = "enter:execute(mpd-fzf _plfile-replace {}),alt-enter:execute-silent(mpd-fzf _plfile-append {})"
+
+func cmdPlaylistFiles(extraDirs []string) {
+	allTracks := loadTracks()
+	files := findPlaylistFiles(playlistFileDirs(extraDirs))
+	format := playlistFileFormatter()
+
+	fzf := fzfcmd("1", playlistFileBindings)
+	in, err := fzf.StdinPipe()
+	fail(err)
+	fail(fzf.Start())
+	for _, path := range files {
+		fmt.Fprintln(in, format(path, loadPlaylistFile(path, allTracks)))
+	}
+	fail(in.Close())
+	fail(ignoreExitInterrupt(fzf.Wait()))
+}
+
+func cmdPlaylistFileLoad(client *mpd.Client, path string, replace bool) {
+	tracks := loadPlaylistFile(path, loadTracks())
+	if replace {
+		fail(client.Clear())
+	}
 	for _, t := range tracks {
-		fmt.Fprintln(in, format(t))
+		fail(client.Add(t.Path))
+	}
+	if replace && len(tracks) > 0 {
+		fail(client.Play(0))
+	}
+}
+
+// unifiedFormatter renders a row tagged with its kind ("track", "album"
+// or "playlist") so a single fzf prompt can search across all three. kind
+// is its own leading field so splitUnified can recover it verbatim; the
+// bracketed tag folded into info is just for display.
+func unifiedFormatter() func(kind, info, duration, id string) string {
+	width := termWidth() - 5 - 1
+	return func(kind, info, duration, id string) string {
+		tagged := strings.Replace("["+kind+"] "+info, delimiter, " ", -1)
+		tagged = alignLeftRight(width-len(duration), tagged, delimiter+duration)
+		return kind + delimiter + tagged + delimiter + id
+	}
+}
+
+func cmdAll(client *mpd.Client) {
+	tracks := loadTracks()
+	albums := groupByAlbum(tracks)
+	playlists, err := client.ListPlaylists()
+	fail(err)
+	format := unifiedFormatter()
+
+	fzf := fzfcmd("2", unifiedBindings)
+	in, err := fzf.StdinPipe()
+	fail(err)
+	fail(fzf.Start())
+	for _, t := range tracks {
+		duration := formatDurationString(t.Time)
+		fmt.Fprintln(in, format("track", trackInfo(t), duration, t.Path))
+	}
+	for _, a := range albums {
+		info := fmt.Sprintf("%s - %s (%d tracks)", a.Artist, a.Name, len(a.Tracks))
+		duration := formatDurationString(sumDurations(trackTimes(a.Tracks)))
+		fmt.Fprintln(in, format("album", info, duration, albumKey(a.Artist, a.Name)))
+	}
+	for _, p := range playlists {
+		songs, err := client.ListPlaylistInfo(p.Name)
+		fail(err)
+		info := fmt.Sprintf("%s (%d tracks)", p.Name, len(songs))
+		duration := formatDurationString(sumDurations(songTimes(songs)))
+		fmt.Fprintln(in, format("playlist", info, duration, p.Name))
 	}
 	fail(in.Close())
 	fail(ignoreExitInterrupt(fzf.Wait()))
 }
 
+func splitUnified(line string) (kind, id string) {
+	fields := strings.SplitN(line, delimiter, 4)
+	if len(fields) != 4 {
+		failNotify("mpd-fzf: split assertion failure")
+	}
+	return fields[0], fields[3]
+}
+
+func cmdUnifiedEnter(client *mpd.Client, line string) {
+	kind, id := splitUnified(line)
+	switch kind {
+	case "track":
+		mpdSelect(client, id, true)
+	case "album":
+		artist, name := splitAlbumKey(id)
+		runTracksFzf(tracksInAlbum(loadTracks(), artist, name))
+	case "playlist":
+		cmdPlaylistTracks(client, id)
+	}
+}
+
+func cmdUnifiedQueue(client *mpd.Client, line string) {
+	kind, id := splitUnified(line)
+	switch kind {
+	case "track":
+		mpdSelect(client, id, false)
+	case "album":
+		artist, name := splitAlbumKey(id)
+		for _, t := range tracksInAlbum(loadTracks(), artist, name) {
+			mpdSelect(client, t.Path, false)
+		}
+	case "playlist":
+		songs, err := client.ListPlaylistInfo(id)
+		fail(err)
+		for _, s := range songs {
+			mpdSelect(client, s.Path, false)
+		}
+	}
+}
+
 func main() {
-	args := os.Args[1:]
-	if len(args) > 0 {
-		if len(args) == 2 {
-			cmd, path := args[0], args[1]
-			// undocumented subcommands
-			switch cmd {
-			case "_play":
-				cmdSelect(path, true)
-			case "_queue":
-				cmdSelect(path, false)
-			}
+	host := flag.String("host", "", "MPD host or unix socket path (overrides MPD_HOST and mpd.conf)")
+	port := flag.String("port", "", "MPD port (overrides MPD_PORT and mpd.conf)")
+	password := flag.String("password", "", "MPD password (overrides mpd.conf)")
+	flag.Parse()
+
+	// Export any --host/--port/--password flags as MPD_HOST/MPD_PORT so
+	// the subcommands fzf spawns on our keybindings inherit them too.
+	if *host != "" {
+		os.Setenv("MPD_HOST", *host)
+	}
+	if *port != "" {
+		os.Setenv("MPD_PORT", *port)
+	}
+	if *password != "" {
+		envHost := os.Getenv("MPD_HOST")
+		if i := strings.Index(envHost, "@"); i >= 0 {
+			envHost = envHost[i+1:]
 		}
-		fail(errors.New("Usage: mpd-fzf (no arguments)"))
+		if envHost == "" {
+			envHost = "localhost"
+		}
+		os.Setenv("MPD_HOST", *password+"@"+envHost)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		cmdList()
+		return
+	}
+	cmd, rest := args[0], args[1:]
+
+	// Commands that don't need a live MPD connection.
+	switch cmd {
+	case "playlistfiles":
+		cmdPlaylistFiles(rest)
+		return
+	case "scan":
+		failOn(len(rest) != 1, "Usage: mpd-fzf scan <dir>")
+		cmdScan(rest[0])
+		return
+	}
+
+	client := dialMPD(*host, *port, *password)
+	defer client.Close()
+	switch cmd {
+	case "playlists":
+		cmdPlaylists(client)
+		return
+	case "all":
+		cmdAll(client)
+		return
+	// undocumented subcommands
+	case "_play":
+		failOn(len(rest) != 1, "usage")
+		cmdSelect(client, rest[0], true)
+		return
+	case "_queue":
+		failOn(len(rest) != 1, "usage")
+		cmdSelect(client, rest[0], false)
+		return
+	case "_playlist-play":
+		failOn(len(rest) != 1, "usage")
+		cmdPlaylistPlay(client, lastField(rest[0]))
+		return
+	case "_playlist-append":
+		failOn(len(rest) != 1, "usage")
+		cmdPlaylistAppend(client, lastField(rest[0]))
+		return
+	case "_unified-enter":
+		failOn(len(rest) != 1, "usage")
+		cmdUnifiedEnter(client, rest[0])
+		return
+	case "_unified-queue":
+		failOn(len(rest) != 1, "usage")
+		cmdUnifiedQueue(client, rest[0])
+		return
+	case "_plfile-replace":
+		failOn(len(rest) != 1, "usage")
+		cmdPlaylistFileLoad(client, lastField(rest[0]), true)
+		return
+	case "_plfile-append":
+		failOn(len(rest) != 1, "usage")
+		cmdPlaylistFileLoad(client, lastField(rest[0]), false)
+		return
+	case "_outputs":
+		cmdOutputs(client)
+		return
+	case "_outputs-list":
+		cmdOutputsList(client)
+		return
+	case "_toggle-output":
+		failOn(len(rest) != 1, "usage")
+		cmdToggleOutput(client, lastField(rest[0]))
+		return
+	case "_pause":
+		cmdPauseToggle(client)
+		return
+	case "_next":
+		fail(client.Next())
+		return
+	case "_prev":
+		fail(client.Previous())
+		return
+	case "_random":
+		cmdRandomToggle(client)
+		return
+	case "_clear":
+		fail(client.Clear())
+		return
+	case "_volup":
+		cmdVolAdjust(client, 5)
+		return
+	case "_voldown":
+		cmdVolAdjust(client, -5)
+		return
+	case "_header":
+		cmdHeader(client)
+		return
 	}
-	cmdList()
+	failOn(true, "Usage: mpd-fzf [playlists|all|playlistfiles [dir...]|scan <dir>]")
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseExtinf(t *testing.T) {
+	cases := []struct {
+		name       string
+		line       string
+		wantTime   string
+		wantArtist string
+		wantTitle  string
+	}{
+		{"artist and title", "#EXTINF:215,Pink Floyd - Money", "215", "Pink Floyd", "Money"},
+		{"title only", "#EXTINF:123,Some Title", "123", "", "Some Title"},
+		{"no comma", "#EXTINF:123", "", "", ""},
+		{"dash in title", "#EXTINF:10,A - B - C", "10", "A", "B - C"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			track := parseExtinf(c.line)
+			if track.Time != c.wantTime || track.Artist != c.wantArtist || track.Title != c.wantTitle {
+				t.Errorf("parseExtinf(%q) = {Time:%q Artist:%q Title:%q}, want {Time:%q Artist:%q Title:%q}",
+					c.line, track.Time, track.Artist, track.Title, c.wantTime, c.wantArtist, c.wantTitle)
+			}
+		})
+	}
+}
+
+func TestParseM3U(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.m3u")
+	body := "#EXTM3U\n" +
+		"#EXTINF:215,Pink Floyd - Money\n" +
+		"dark-side/money.mp3\n" +
+		"#EXTINF:300,No Artist Here\n" +
+		"/abs/path/track.mp3\n" +
+		"plain-no-extinf.mp3\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := parseM3U(path)
+	if len(tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3", len(tracks))
+	}
+	if tracks[0].Artist != "Pink Floyd" || tracks[0].Title != "Money" {
+		t.Errorf("tracks[0] = %+v", tracks[0])
+	}
+	if tracks[0].Path != filepath.Join(dir, "dark-side/money.mp3") {
+		t.Errorf("tracks[0].Path = %q", tracks[0].Path)
+	}
+	if tracks[1].Title != "No Artist Here" || tracks[1].Path != "/abs/path/track.mp3" {
+		t.Errorf("tracks[1] = %+v", tracks[1])
+	}
+	if tracks[2].Title != "" || tracks[2].Path != filepath.Join(dir, "plain-no-extinf.mp3") {
+		t.Errorf("tracks[2] = %+v", tracks[2])
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.pls")
+	body := "[playlist]\n" +
+		"File1=track-a.mp3\n" +
+		"Title1=Track A\n" +
+		"Length1=180\n" +
+		"File2=/abs/track-b.mp3\n" +
+		"Title2=Track B\n" +
+		"Length2=240\n" +
+		"NumberOfEntries=2\n" +
+		"Version=2\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := parsePLS(path)
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+	if tracks[0].Title != "Track A" || tracks[0].Time != "180" {
+		t.Errorf("tracks[0] = %+v", tracks[0])
+	}
+	if tracks[0].Path != filepath.Join(dir, "track-a.mp3") {
+		t.Errorf("tracks[0].Path = %q", tracks[0].Path)
+	}
+	if tracks[1].Title != "Track B" || tracks[1].Path != "/abs/track-b.mp3" {
+		t.Errorf("tracks[1] = %+v", tracks[1])
+	}
+}
+
+func TestParseNSP(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.nsp")
+	body := `{"artist":"Boards of Canada","year_min":1998,"year_max":2005,"sort":"date","limit":1}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	all := []*Track{
+		{Artist: "Boards of Canada", Title: "Roygbiv", Date: "1998"},
+		{Artist: "Boards of Canada", Title: "Dayvan Cowboy", Date: "2005"},
+		{Artist: "Boards of Canada", Title: "Too Old", Date: "2013"},
+		{Artist: "Someone Else", Title: "Unrelated", Date: "2000"},
+	}
+
+	tracks := parseNSP(path, all)
+	if len(tracks) != 1 {
+		t.Fatalf("got %d tracks, want 1 (limit)", len(tracks))
+	}
+	if tracks[0].Title != "Roygbiv" {
+		t.Errorf("tracks[0].Title = %q, want the oldest matching track first (sort=date)", tracks[0].Title)
+	}
+}
+
+func TestParseNSPNoCriteria(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.nsp")
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	all := []*Track{{Title: "A"}, {Title: "B"}}
+	tracks := parseNSP(path, all)
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2 (no criteria matches everything)", len(tracks))
+	}
+}
@@ -0,0 +1,341 @@
+// Package mpd speaks the MPD text protocol directly over a TCP or Unix
+// socket connection, so mpd-fzf can talk to a music daemon without
+// shelling out to the mpc binary.
+package mpd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Client is a connection to a running MPD instance.
+type Client struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// Dial connects to MPD at addr over network ("tcp" or "unix") and reads
+// the protocol greeting.
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	text := textproto.NewConn(conn)
+	line, err := text.ReadLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK MPD") {
+		conn.Close()
+		return nil, fmt.Errorf("mpd: unexpected greeting %q", line)
+	}
+	return &Client{conn: conn, text: text}, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// quote escapes a string for use as an MPD command argument: " \ and '
+// are backslash-escaped and the result is wrapped in double quotes.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\', '\'':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// request sends a command line and collects the response lines up to the
+// terminating "OK", returning an error built from the "ACK" line if MPD
+// rejected the command.
+func (c *Client) request(format string, args ...interface{}) ([]string, error) {
+	cmd := fmt.Sprintf(format, args...)
+	if err := c.text.PrintfLine("%s", cmd); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "OK" {
+			return lines, nil
+		}
+		if strings.HasPrefix(line, "ACK ") {
+			return nil, errors.New(line)
+		}
+		lines = append(lines, line)
+	}
+}
+
+// keyValue splits an MPD response line of the form "key: value".
+func keyValue(line string) (string, string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimPrefix(line[i+1:], " ")
+}
+
+// Password authenticates the connection.
+func (c *Client) Password(password string) error {
+	_, err := c.request("password %s", quote(password))
+	return err
+}
+
+// Add appends path to the current playlist.
+func (c *Client) Add(path string) error {
+	_, err := c.request("add %s", quote(path))
+	return err
+}
+
+// Play starts playback at the given playlist position (0-based).
+func (c *Client) Play(pos int) error {
+	_, err := c.request("play %d", pos)
+	return err
+}
+
+// Clear empties the current playlist.
+func (c *Client) Clear() error {
+	_, err := c.request("clear")
+	return err
+}
+
+// Song is a single entry in the current playlist or a query result.
+type Song struct {
+	Pos    int
+	Path   string
+	Title  string
+	Artist string
+	Album  string
+	Time   string
+}
+
+func parseSongs(lines []string) []Song {
+	var songs []Song
+	var song *Song
+	for _, line := range lines {
+		key, value := keyValue(line)
+		if key == "file" {
+			songs = append(songs, Song{Path: value})
+			song = &songs[len(songs)-1]
+		}
+		if song == nil {
+			continue
+		}
+		switch key {
+		case "Pos":
+			song.Pos, _ = strconv.Atoi(value)
+		case "Title":
+			song.Title = value
+		case "Artist":
+			song.Artist = value
+		case "Album":
+			song.Album = value
+		case "Time":
+			song.Time = value
+		}
+	}
+	return songs
+}
+
+// PlaylistInfo returns the contents of the current playlist.
+func (c *Client) PlaylistInfo() ([]Song, error) {
+	lines, err := c.request("playlistinfo")
+	if err != nil {
+		return nil, err
+	}
+	return parseSongs(lines), nil
+}
+
+// Status is the state reported by MPD's "status" command.
+type Status struct {
+	State    string
+	Volume   int
+	Song     int
+	Random   bool
+	Elapsed  float64
+	Duration float64
+}
+
+// Playlist is a stored playlist as reported by "listplaylists".
+type Playlist struct {
+	Name         string
+	LastModified string
+}
+
+// ListPlaylists returns the names of MPD's stored playlists.
+func (c *Client) ListPlaylists() ([]Playlist, error) {
+	lines, err := c.request("listplaylists")
+	if err != nil {
+		return nil, err
+	}
+	var playlists []Playlist
+	var pl *Playlist
+	for _, line := range lines {
+		key, value := keyValue(line)
+		switch key {
+		case "playlist":
+			playlists = append(playlists, Playlist{Name: value})
+			pl = &playlists[len(playlists)-1]
+		case "Last-Modified":
+			if pl != nil {
+				pl.LastModified = value
+			}
+		}
+	}
+	return playlists, nil
+}
+
+// ListPlaylistInfo returns the tracks stored in the named playlist.
+func (c *Client) ListPlaylistInfo(name string) ([]Song, error) {
+	lines, err := c.request("listplaylistinfo %s", quote(name))
+	if err != nil {
+		return nil, err
+	}
+	return parseSongs(lines), nil
+}
+
+// Status queries MPD's current playback state.
+func (c *Client) Status() (Status, error) {
+	lines, err := c.request("status")
+	if err != nil {
+		return Status{}, err
+	}
+	var st Status
+	for _, line := range lines {
+		key, value := keyValue(line)
+		switch key {
+		case "state":
+			st.State = value
+		case "volume":
+			st.Volume, _ = strconv.Atoi(value)
+		case "song":
+			st.Song, _ = strconv.Atoi(value)
+		case "random":
+			st.Random = value == "1"
+		case "elapsed":
+			st.Elapsed, _ = strconv.ParseFloat(value, 64)
+		case "duration":
+			st.Duration, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	return st, nil
+}
+
+// CurrentSong returns the song at the current playlist position.
+func (c *Client) CurrentSong() (Song, error) {
+	lines, err := c.request("currentsong")
+	if err != nil {
+		return Song{}, err
+	}
+	songs := parseSongs(lines)
+	if len(songs) == 0 {
+		return Song{}, nil
+	}
+	return songs[0], nil
+}
+
+// Next skips to the next song in the playlist.
+func (c *Client) Next() error {
+	_, err := c.request("next")
+	return err
+}
+
+// Previous skips to the previous song in the playlist.
+func (c *Client) Previous() error {
+	_, err := c.request("previous")
+	return err
+}
+
+// Pause pauses or resumes playback.
+func (c *Client) Pause(paused bool) error {
+	_, err := c.request("pause %d", boolInt(paused))
+	return err
+}
+
+// SetRandom turns random play on or off.
+func (c *Client) SetRandom(on bool) error {
+	_, err := c.request("random %d", boolInt(on))
+	return err
+}
+
+// SetVol sets the output volume, clamped to 0-100.
+func (c *Client) SetVol(vol int) error {
+	if vol < 0 {
+		vol = 0
+	}
+	if vol > 100 {
+		vol = 100
+	}
+	_, err := c.request("setvol %d", vol)
+	return err
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Output is an audio output device as reported by "outputs".
+type Output struct {
+	ID      string
+	Name    string
+	Enabled bool
+}
+
+// Outputs lists MPD's configured audio outputs.
+func (c *Client) Outputs() ([]Output, error) {
+	lines, err := c.request("outputs")
+	if err != nil {
+		return nil, err
+	}
+	var outputs []Output
+	var out *Output
+	for _, line := range lines {
+		key, value := keyValue(line)
+		switch key {
+		case "outputid":
+			outputs = append(outputs, Output{ID: value})
+			out = &outputs[len(outputs)-1]
+		case "outputname":
+			if out != nil {
+				out.Name = value
+			}
+		case "outputenabled":
+			if out != nil {
+				out.Enabled = value == "1"
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// EnableOutput turns an audio output device on.
+func (c *Client) EnableOutput(id string) error {
+	_, err := c.request("enableoutput %s", id)
+	return err
+}
+
+// DisableOutput turns an audio output device off.
+func (c *Client) DisableOutput(id string) error {
+	_, err := c.request("disableoutput %s", id)
+	return err
+}
@@ -0,0 +1,47 @@
+package mpd
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "foo.mp3", `"foo.mp3"`},
+		{"double quote", `a"b`, `"a\"b"`},
+		{"backslash", `a\b`, `"a\\b"`},
+		{"single quote", "a'b", `"a\'b"`},
+		{"all together", `a"b\c'd`, `"a\"b\\c\'d"`},
+		{"empty", "", `""`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := quote(c.in); got != c.want {
+				t.Errorf("quote(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	cases := []struct {
+		name      string
+		line      string
+		wantKey   string
+		wantValue string
+	}{
+		{"simple", "file: music/track.mp3", "file", "music/track.mp3"},
+		{"no colon", "OK MPD 0.23.5", "OK MPD 0.23.5", ""},
+		{"empty value", "Title:", "Title", ""},
+		{"colon in value", "file: a: b.mp3", "file", "a: b.mp3"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key, value := keyValue(c.line)
+			if key != c.wantKey || value != c.wantValue {
+				t.Errorf("keyValue(%q) = (%q, %q), want (%q, %q)", c.line, key, value, c.wantKey, c.wantValue)
+			}
+		})
+	}
+}
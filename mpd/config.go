@@ -0,0 +1,129 @@
+package mpd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Config holds the subset of mpd.conf that mpd-fzf cares about.
+type Config struct {
+	DbFile            string
+	PlaylistDirectory string
+	BindToAddress     string
+	Port              string
+	Password          string
+}
+
+var confPatterns = map[string]*regexp.Regexp{
+	"db_file":            regexp.MustCompile(`^\s*db_file\s*"([^"]+)"`),
+	"playlist_directory": regexp.MustCompile(`^\s*playlist_directory\s*"([^"]+)"`),
+	"bind_to_address":    regexp.MustCompile(`^\s*bind_to_address\s*"([^"]+)"`),
+	"port":               regexp.MustCompile(`^\s*port\s*"([^"]+)"`),
+	"password":           regexp.MustCompile(`^\s*password\s*"([^"]+)"`),
+}
+
+func expandHome(path, home string) string {
+	if strings.HasPrefix(path, "~/") {
+		path = strings.Replace(path, "~", home, 1)
+	}
+	return path
+}
+
+// FindConfigFile searches the usual mpd.conf locations and returns the
+// first one that exists.
+func FindConfigFile() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	home := usr.HomeDir
+	paths := []string{
+		filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "/mpd/mpd.conf"),
+		filepath.Join(home, ".config", "/mpd/mpd.conf"),
+		filepath.Join(home, ".mpdconf"),
+		"/etc/mpd.conf",
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no config file found")
+}
+
+// unreachableBindValues are bind_to_address values that tell MPD to listen
+// on every interface. They don't identify a specific host a client can
+// connect back to, so they're never useful as a connect address.
+var unreachableBindValues = map[string]bool{
+	"any":     true,
+	"all":     true,
+	"*":       true,
+	"0.0.0.0": true,
+}
+
+// chooseBindAddress picks the bind_to_address value (mpd.conf allows
+// several) a client should actually connect to: a socket path is the only
+// one a client can always reuse, so it wins over a network address; values
+// like "any" or "0.0.0.0" identify no specific host and are skipped.
+func chooseBindAddress(binds []string) string {
+	for _, b := range binds {
+		if strings.HasPrefix(b, "/") {
+			return b
+		}
+	}
+	for _, b := range binds {
+		if !unreachableBindValues[strings.ToLower(b)] {
+			return b
+		}
+	}
+	return ""
+}
+
+// ParseConfig reads db_file, playlist_directory, bind_to_address, port and
+// password out of an mpd.conf file. Fields that aren't present are left as the zero value.
+func ParseConfig(path string) (Config, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return Config{}, err
+	}
+	home := usr.HomeDir
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	var cfg Config
+	var binds []string
+	scan := bufio.NewScanner(file)
+	for scan.Scan() {
+		line := scan.Text()
+		if m := confPatterns["db_file"].FindStringSubmatch(line); m != nil && cfg.DbFile == "" {
+			cfg.DbFile = expandHome(m[1], home)
+		}
+		if m := confPatterns["playlist_directory"].FindStringSubmatch(line); m != nil && cfg.PlaylistDirectory == "" {
+			cfg.PlaylistDirectory = expandHome(m[1], home)
+		}
+		if m := confPatterns["bind_to_address"].FindStringSubmatch(line); m != nil {
+			binds = append(binds, m[1])
+		}
+		if m := confPatterns["port"].FindStringSubmatch(line); m != nil && cfg.Port == "" {
+			cfg.Port = m[1]
+		}
+		if m := confPatterns["password"].FindStringSubmatch(line); m != nil && cfg.Password == "" {
+			// mpd.conf writes "password "secret@read,add,control""
+			cfg.Password = strings.SplitN(m[1], "@", 2)[0]
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return Config{}, err
+	}
+	cfg.BindToAddress = chooseBindAddress(binds)
+	return cfg, nil
+}
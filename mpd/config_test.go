@@ -0,0 +1,81 @@
+package mpd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConf(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mpd.conf")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseConfig(t *testing.T) {
+	path := writeConf(t, `
+music_directory		"~/music"
+db_file			"~/.cache/mpd/database"
+playlist_directory	"~/.cache/mpd/playlists"
+bind_to_address		"127.0.0.1"
+port			"6600"
+password		"secret@read,add,control"
+`)
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := homeDirFor(t)
+	if cfg.DbFile != home+"/.cache/mpd/database" {
+		t.Errorf("DbFile = %q", cfg.DbFile)
+	}
+	if cfg.PlaylistDirectory != home+"/.cache/mpd/playlists" {
+		t.Errorf("PlaylistDirectory = %q", cfg.PlaylistDirectory)
+	}
+	if cfg.BindToAddress != "127.0.0.1" {
+		t.Errorf("BindToAddress = %q", cfg.BindToAddress)
+	}
+	if cfg.Port != "6600" {
+		t.Errorf("Port = %q", cfg.Port)
+	}
+	if cfg.Password != "secret" {
+		t.Errorf("Password = %q", cfg.Password)
+	}
+}
+
+func homeDirFor(t *testing.T) string {
+	t.Helper()
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return home
+}
+
+func TestChooseBindAddress(t *testing.T) {
+	cases := []struct {
+		name  string
+		binds []string
+		want  string
+	}{
+		{"none", nil, ""},
+		{"single address", []string{"192.168.1.5"}, "192.168.1.5"},
+		{"any falls back", []string{"any"}, ""},
+		{"0.0.0.0 falls back", []string{"0.0.0.0"}, ""},
+		{"wildcard falls back", []string{"*"}, ""},
+		{"any then address, address wins", []string{"any", "192.168.1.5"}, "192.168.1.5"},
+		{"socket preferred over address", []string{"192.168.1.5", "/run/mpd/socket"}, "/run/mpd/socket"},
+		{"socket preferred even first", []string{"/run/mpd/socket", "any"}, "/run/mpd/socket"},
+		{"case insensitive any", []string{"ANY"}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := chooseBindAddress(c.binds); got != c.want {
+				t.Errorf("chooseBindAddress(%v) = %q, want %q", c.binds, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !taglib
+
+package tags
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/dhowden/tag"
+)
+
+// NewReader returns the default, pure-Go tag reader, covering MP3, FLAC,
+// OGG and M4A. Build with -tags taglib to use the cgo taglib backend
+// instead.
+func NewReader() Reader {
+	return dhowdenReader{}
+}
+
+type dhowdenReader struct{}
+
+func (dhowdenReader) Read(path string) (*Track, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := tag.ReadFrom(file)
+	if err != nil {
+		return nil, err
+	}
+	return &Track{
+		Path:     path,
+		Filename: filepath.Base(path),
+		Title:    m.Title(),
+		Artist:   m.Artist(),
+		Album:    m.Album(),
+		Genre:    m.Genre(),
+		Date:     strconv.Itoa(m.Year()),
+	}, nil
+}
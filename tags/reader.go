@@ -0,0 +1,20 @@
+// Package tags reads metadata directly out of audio files, as a fallback
+// for when MPD's database is stale or MPD isn't running at all.
+package tags
+
+// Track is the metadata read from a single audio file.
+type Track struct {
+	Path     string
+	Filename string
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	Date     string
+	Time     string
+}
+
+// Reader reads the Track metadata out of a single audio file.
+type Reader interface {
+	Read(path string) (*Track, error)
+}
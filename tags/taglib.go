@@ -0,0 +1,38 @@
+//go:build taglib
+
+package tags
+
+import (
+	"path/filepath"
+	"strconv"
+
+	taglib "github.com/wtolson/go-taglib"
+)
+
+// NewReader returns a cgo-backed tag reader using taglib, which covers a
+// wider range of formats than the pure-Go reader at the cost of a
+// build-time dependency on libtag.
+func NewReader() Reader {
+	return taglibReader{}
+}
+
+type taglibReader struct{}
+
+func (taglibReader) Read(path string) (*Track, error) {
+	file, err := taglib.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return &Track{
+		Path:     path,
+		Filename: filepath.Base(path),
+		Title:    file.Title(),
+		Artist:   file.Artist(),
+		Album:    file.Album(),
+		Genre:    file.Genre(),
+		Date:     strconv.Itoa(file.Year()),
+		Time:     strconv.Itoa(file.Length()),
+	}, nil
+}